@@ -0,0 +1,40 @@
+package cstore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// atomicWriteFile writes through a sibling temp file and renames it into
+// place, so a crash mid-write can't leave filePath truncated: write writes
+// the encoded value into a temp file, atomicWriteFile fsyncs it, then
+// renames it over filePath.
+func atomicWriteFile(filePath string, write func(f *os.File) error) error {
+	dir := filepath.Dir(filePath)
+	pattern := fmt.Sprintf("%s.tmp-%d-*", filepath.Base(filePath), os.Getpid())
+
+	tmp, err := ioutil.TempFile(dir, pattern)
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, filePath)
+}
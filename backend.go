@@ -0,0 +1,274 @@
+package cstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Backend is the storage layer a CStore persists encoded bytes to. The
+// format layer (TomlFile/JsonFile/YamlFile today) still owns (de)serializing
+// the caller's value; Backend only owns where the resulting bytes live.
+type Backend interface {
+	Load(key string) ([]byte, error)
+	Store(key string, data []byte) error
+	Remove(key string) error
+}
+
+// notExistError builds an os.IsNotExist-compatible error for Backend
+// implementations that have no filesystem-backed error of their own (Redis,
+// memory, object storage). os.IsNotExist special-cases *os.PathError rather
+// than calling errors.Is, so a bespoke error type wrapping os.ErrNotExist
+// would not satisfy it; *os.PathError is what os.Open/os.Stat themselves
+// return for a missing file, so existing os.IsNotExist(err) callers keep
+// working unchanged.
+func notExistError(op, key string) error {
+	return &os.PathError{Op: op, Path: key, Err: os.ErrNotExist}
+}
+
+// FileBackend is the default Backend, storing each entry as its own file.
+// It wraps the same os.Create/os.Open calls the TomlFile/JsonFile/YamlFile
+// serializers used before Backend existed.
+type FileBackend struct{}
+
+func (b *FileBackend) Load(key string) ([]byte, error) {
+	return ioutil.ReadFile(key)
+}
+
+func (b *FileBackend) Store(key string, data []byte) error {
+	f, err := os.Create(key)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(data)
+	return err
+}
+
+func (b *FileBackend) Remove(key string) error {
+	return os.Remove(key)
+}
+
+// MemoryBackend keeps every entry in a map guarded by a mutex. It's mainly
+// useful in tests, in place of the removeBaseDir dance the file-based
+// serializers require between test cases.
+func MemoryBackend() Backend {
+	return &memoryBackend{
+		data: make(map[string][]byte),
+	}
+}
+
+type memoryBackend struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+func (b *memoryBackend) Load(key string) ([]byte, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	data, ok := b.data[key]
+	if !ok {
+		return nil, notExistError("load", key)
+	}
+
+	// return a copy so the caller can't mutate our stored bytes.
+	cp := make([]byte, len(data))
+	copy(cp, data)
+	return cp, nil
+}
+
+func (b *memoryBackend) Store(key string, data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cp := make([]byte, len(data))
+	copy(cp, data)
+	b.data[key] = cp
+	return nil
+}
+
+func (b *memoryBackend) Remove(key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.data[key]; !ok {
+		return notExistError("remove", key)
+	}
+
+	delete(b.data, key)
+	return nil
+}
+
+// RedisClient is the subset of a Redis client Backend needs. Callers wrap
+// whichever client library they use (go-redis, redigo, ...) to satisfy it.
+type RedisClient interface {
+	Get(key string) (string, error)
+	Set(key string, value string) error
+	Del(key string) error
+}
+
+// RedisBackend stores each entry under prefix+key, mirroring the
+// manager-name+name composite key CStore entries are already addressed by.
+func RedisBackend(client RedisClient, prefix string) Backend {
+	return &redisBackend{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+type redisBackend struct {
+	client RedisClient
+	prefix string
+}
+
+func (b *redisBackend) Load(key string) ([]byte, error) {
+	v, err := b.client.Get(b.prefix + key)
+	if err != nil {
+		return nil, err
+	}
+
+	if v == "" {
+		return nil, notExistError("load", key)
+	}
+
+	return []byte(v), nil
+}
+
+func (b *redisBackend) Store(key string, data []byte) error {
+	return b.client.Set(b.prefix+key, string(data))
+}
+
+func (b *redisBackend) Remove(key string) error {
+	return b.client.Del(b.prefix + key)
+}
+
+// ObjectStore is the subset of an object-storage client (S3, GCS, ...)
+// Backend needs. Callers wrap whichever SDK client they use to satisfy it.
+type ObjectStore interface {
+	GetObject(key string) ([]byte, error)
+	PutObject(key string, data []byte) error
+	DeleteObject(key string) error
+}
+
+// ObjectStorageBackend stores each entry as an object under prefix+key.
+func ObjectStorageBackend(store ObjectStore, prefix string) Backend {
+	return &objectStorageBackend{
+		store:  store,
+		prefix: prefix,
+	}
+}
+
+type objectStorageBackend struct {
+	store  ObjectStore
+	prefix string
+}
+
+func (b *objectStorageBackend) Load(key string) ([]byte, error) {
+	data, err := b.store.GetObject(b.prefix + key)
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, notExistError("load", key)
+	}
+
+	return data, nil
+}
+
+func (b *objectStorageBackend) Store(key string, data []byte) error {
+	return b.store.PutObject(b.prefix+key, data)
+}
+
+func (b *objectStorageBackend) Remove(key string) error {
+	return b.store.DeleteObject(b.prefix + key)
+}
+
+// encodeFormat serializes p the same way the file-based serializers do, but
+// to an in-memory buffer instead of a file, so a Backend can store the
+// result anywhere.
+func encodeFormat(format Format, p interface{}) ([]byte, error) {
+	switch format {
+	case TOML:
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(p); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case JSON:
+		return json.Marshal(p)
+	case YAML:
+		return yaml.Marshal(p)
+	default:
+		formatRegistryMu.RLock()
+		rf, ok := customFormats[format]
+		formatRegistryMu.RUnlock()
+
+		if ok {
+			return rf.enc.Encode(p)
+		}
+		return nil, fmt.Errorf("invalid format type: %d", format)
+	}
+}
+
+// decodeFormat reverses encodeFormat.
+func decodeFormat(format Format, data []byte, p interface{}) error {
+	switch format {
+	case TOML:
+		_, err := toml.Decode(string(data), p)
+		return err
+	case JSON:
+		return json.Unmarshal(data, p)
+	case YAML:
+		return yaml.Unmarshal(data, p)
+	default:
+		formatRegistryMu.RLock()
+		rf, ok := customFormats[format]
+		formatRegistryMu.RUnlock()
+
+		if ok {
+			return rf.dec.Decode(data, p)
+		}
+		return fmt.Errorf("invalid format type: %d", format)
+	}
+}
+
+// BackendSerializer adapts a Backend plus a Format into a Serializable, so
+// CStore.Save/Get can route through any Backend the same way they route
+// through the file-based ones.
+type BackendSerializer struct {
+	Key     string
+	Format  Format
+	Backend Backend
+}
+
+func (s *BackendSerializer) Load(p interface{}) error {
+	data, err := s.Backend.Load(s.Key)
+	if err != nil {
+		return err
+	}
+
+	return decodeFormat(s.Format, data, p)
+}
+
+func (s *BackendSerializer) Store(p interface{}) error {
+	data, err := encodeFormat(s.Format, p)
+	if err != nil {
+		return err
+	}
+
+	return s.Backend.Store(s.Key, data)
+}
+
+func (s *BackendSerializer) Remove() error {
+	return s.Backend.Remove(s.Key)
+}
@@ -0,0 +1,150 @@
+package cstore
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// fakeRedisClient is a minimal in-memory stand-in for a real RedisClient, so
+// RedisBackend can be exercised without a live Redis server.
+type fakeRedisClient struct {
+	data map[string]string
+}
+
+func newFakeRedisClient() *fakeRedisClient {
+	return &fakeRedisClient{data: make(map[string]string)}
+}
+
+func (c *fakeRedisClient) Get(key string) (string, error) {
+	return c.data[key], nil
+}
+
+func (c *fakeRedisClient) Set(key string, value string) error {
+	c.data[key] = value
+	return nil
+}
+
+func (c *fakeRedisClient) Del(key string) error {
+	delete(c.data, key)
+	return nil
+}
+
+func TestRedisBackend(t *testing.T) {
+	client := newFakeRedisClient()
+	backend := RedisBackend(client, "cstore:")
+
+	if err := backend.Store("sample", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	if client.data["cstore:sample"] != "hello" {
+		t.Fatalf("expected backend to store under the prefixed key, got: %v", client.data)
+	}
+
+	got, err := backend.Load("sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != "hello" {
+		t.Errorf("expect:%s but %s", "hello", got)
+	}
+
+	if err := backend.Remove("sample"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := backend.Load("sample"); !os.IsNotExist(err) {
+		t.Errorf("expected os.IsNotExist after Remove, got: %v", err)
+	}
+}
+
+// fakeObjectStore is a minimal in-memory stand-in for a real ObjectStore, so
+// ObjectStorageBackend/S3Source can be exercised without a live S3-compatible
+// service.
+type fakeObjectStore struct {
+	data map[string][]byte
+}
+
+func newFakeObjectStore() *fakeObjectStore {
+	return &fakeObjectStore{data: make(map[string][]byte)}
+}
+
+func (s *fakeObjectStore) GetObject(key string) ([]byte, error) {
+	data, ok := s.data[key]
+	if !ok {
+		return nil, nil
+	}
+	return data, nil
+}
+
+func (s *fakeObjectStore) PutObject(key string, data []byte) error {
+	s.data[key] = data
+	return nil
+}
+
+func (s *fakeObjectStore) DeleteObject(key string) error {
+	if _, ok := s.data[key]; !ok {
+		return fmt.Errorf("no such object: %s", key)
+	}
+	delete(s.data, key)
+	return nil
+}
+
+func TestObjectStorageBackend(t *testing.T) {
+	store := newFakeObjectStore()
+	backend := ObjectStorageBackend(store, "cstore/")
+
+	if err := backend.Store("sample", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(store.data["cstore/sample"]) != "hello" {
+		t.Fatalf("expected backend to store under the prefixed key, got: %v", store.data)
+	}
+
+	got, err := backend.Load("sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != "hello" {
+		t.Errorf("expect:%s but %s", "hello", got)
+	}
+
+	if err := backend.Remove("sample"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := backend.Load("sample"); !os.IsNotExist(err) {
+		t.Errorf("expected os.IsNotExist after Remove, got: %v", err)
+	}
+}
+
+// TestManagerBackendKeyIsLogical confirms a CStore built with WithBackend is
+// keyed by manager-name+name rather than the Manager's local baseDirPath, so
+// a Redis/object-storage-backed entry doesn't leak the filesystem layout of
+// whichever machine created it.
+func TestManagerBackendKeyIsLogical(t *testing.T) {
+	client := newFakeRedisClient()
+
+	m, err := NewManager("testing", BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := m.New("sample.json", JSON, WithBackend(RedisBackend(client, "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.SaveWithoutValidate(&Sample{Name: "sample name"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "testing/sample.json"
+	if _, ok := client.data[want]; !ok {
+		t.Fatalf("expected entry stored under %q, got keys: %v", want, client.data)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sync"
 
 	"gopkg.in/yaml.v2"
 
@@ -24,6 +25,17 @@ const (
 	YAML
 )
 
+// formatCodec lists the formats NewCStore knows how to handle, so both the
+// file-based switch and the Backend path (see encodeFormat/decodeFormat in
+// backend.go) validate against the same set. RegisterFormat adds to it at
+// runtime, so it's guarded by formatRegistryMu (registry.go) like
+// customFormats.
+var formatCodec = map[Format]struct{}{
+	TOML: {},
+	JSON: {},
+	YAML: {},
+}
+
 func NewManager(name, baseDirPath string) (*Manager, error) {
 	err := createDir(baseDirPath)
 	if err != nil {
@@ -40,6 +52,7 @@ func NewManager(name, baseDirPath string) (*Manager, error) {
 type Manager struct {
 	name        string
 	baseDirPath string
+	mu          sync.RWMutex
 	csMap       map[string]*CStore
 }
 
@@ -47,12 +60,30 @@ func (m *Manager) Name() string {
 	return m.name
 }
 
-func (m *Manager) New(name string, format Format) (*CStore, error) {
-	cs, err := NewCStore(name, m.baseDirPath+string(os.PathSeparator)+name, format)
+func (m *Manager) New(name string, format Format, opts ...CStoreOption) (*CStore, error) {
+	var cfg cstoreConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	// A Backend other than the default stores entries under a key it
+	// addresses itself (a Redis/object-storage namespace, an in-memory
+	// map), not a filesystem path, so it gets the manager-name+name
+	// composite key that means to that Backend instead of m's local
+	// baseDirPath leaking into it.
+	key := m.baseDirPath + string(os.PathSeparator) + name
+	if cfg.backend != nil {
+		key = m.name + "/" + name
+	}
+
+	cs, err := NewCStore(name, key, format, opts...)
 	if err != nil {
 		return nil, err
 	}
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if m.csMap == nil {
 		m.csMap = make(map[string]*CStore)
 	}
@@ -62,6 +93,9 @@ func (m *Manager) New(name string, format Format) (*CStore, error) {
 }
 
 func (m *Manager) Get(name string) *CStore {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	if m.csMap == nil {
 		return nil
 	}
@@ -74,6 +108,9 @@ func (m *Manager) Get(name string) *CStore {
 }
 
 func (m *Manager) Remove(name string) *CStore {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if m.csMap == nil {
 		return nil
 	}
@@ -86,6 +123,35 @@ func (m *Manager) Remove(name string) *CStore {
 	return nil
 }
 
+// List returns the names of every CStore currently registered with m, in no
+// particular order, so callers can enumerate entries without reaching into
+// the (unexported, mutex-guarded) underlying map.
+func (m *Manager) List() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.csMap))
+	for name := range m.csMap {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// Range calls f for each CStore registered with m, stopping early if f
+// returns false. f is called while m's read lock is held, so it must not
+// call back into m (New/Get/Remove/List/Range) or it will deadlock.
+func (m *Manager) Range(f func(name string, cs *CStore) bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for name, cs := range m.csMap {
+		if !f(name, cs) {
+			return
+		}
+	}
+}
+
 type Validatable interface {
 	Validate() error
 }
@@ -137,23 +203,115 @@ func SaveWithoutValidate(p interface{}, s Serializable) error {
 	return nil
 }
 
-func NewCStore(name, filePath string, format Format) (*CStore, error) {
+// CStoreOption customizes how a CStore is built, e.g. to route Save/Load/
+// Remove through a backend other than the local filesystem.
+type CStoreOption func(*cstoreConfig)
+
+type cstoreConfig struct {
+	backend     Backend
+	atomicWrite bool
+	fileLock    bool
+	schema      []byte
+	keyProvider KeyProvider
+}
+
+// WithBackend routes Save/Load/Remove through backend instead of the default
+// FileBackend. The format given to NewCStore/Manager.New still handles
+// (de)serializing the caller's value; backend only decides where the
+// resulting bytes live (a file, Redis, an in-memory map, object storage...).
+func WithBackend(backend Backend) CStoreOption {
+	return func(c *cstoreConfig) {
+		c.backend = backend
+	}
+}
+
+// WithAtomicWrite controls whether Store writes to a temp file and renames
+// it into place instead of truncating the target file in place. It is on
+// by default; pass false to skip the extra temp-file-plus-rename overhead.
+// It has no effect when WithBackend or WithEncryption is used, or for a
+// format added via RegisterFormat: those write through FileBackend, which
+// truncates and rewrites the target file in place.
+func WithAtomicWrite(enabled bool) CStoreOption {
+	return func(c *cstoreConfig) {
+		c.atomicWrite = enabled
+	}
+}
+
+// WithFileLock controls whether Load/Store take an OS-level advisory lock
+// around the target file, so two processes sharing a CStore don't corrupt
+// each other's writes. It is on by default; pass false to skip it. It has
+// no effect when WithBackend or WithEncryption is used, or for a format
+// added via RegisterFormat: FileBackend takes no lock.
+func WithFileLock(enabled bool) CStoreOption {
+	return func(c *cstoreConfig) {
+		c.fileLock = enabled
+	}
+}
+
+func NewCStore(name, filePath string, format Format, opts ...CStoreOption) (*CStore, error) {
+	formatRegistryMu.RLock()
+	_, ok := formatCodec[format]
+	formatRegistryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("invalid format type: %d", format)
+	}
+
+	cfg := &cstoreConfig{atomicWrite: true, fileLock: true}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
 	var s Serializable
-	switch format {
-	case TOML:
-		s = &TomlFile{
-			FilePath: filePath,
+	if cfg.keyProvider != nil {
+		backend := cfg.backend
+		if backend == nil {
+			backend = &FileBackend{}
 		}
-	case JSON:
-		s = &JsonFile{
-			FilePath: filePath,
+		s = newEncryptedFile(filePath, format, backend, cfg.keyProvider)
+	} else if cfg.backend != nil {
+		s = &BackendSerializer{
+			Key:     filePath,
+			Format:  format,
+			Backend: cfg.backend,
 		}
-	case YAML:
-		s = &YamlFile{
-			FilePath: filePath,
+	} else {
+		switch format {
+		case TOML:
+			s = &TomlFile{
+				FilePath:    filePath,
+				AtomicWrite: cfg.atomicWrite,
+				FileLock:    cfg.fileLock,
+			}
+		case JSON:
+			s = &JsonFile{
+				FilePath:    filePath,
+				AtomicWrite: cfg.atomicWrite,
+				FileLock:    cfg.fileLock,
+			}
+		case YAML:
+			s = &YamlFile{
+				FilePath:    filePath,
+				AtomicWrite: cfg.atomicWrite,
+				FileLock:    cfg.fileLock,
+			}
+		default:
+			// a format registered via RegisterFormat: store it as a plain
+			// file, encoded/decoded through its registered Encoder/Decoder.
+			s = &BackendSerializer{
+				Key:     filePath,
+				Format:  format,
+				Backend: &FileBackend{},
+			}
 		}
-	default:
-		return nil, fmt.Errorf("invalid format type: %d", format)
+	}
+
+	if cfg.schema != nil {
+		schemaSerializer, err := newSchemaSerializer(s, cfg.schema)
+		if err != nil {
+			return nil, err
+		}
+		s = schemaSerializer
 	}
 
 	cs := &CStore{
@@ -167,37 +325,65 @@ func NewCStore(name, filePath string, format Format) (*CStore, error) {
 type CStore struct {
 	name       string
 	serializer Serializable
+	mu         sync.RWMutex
 }
 
 func (cs *CStore) Name() string {
 	return cs.name
 }
 
+// Get and Save (and their *WithoutValidate/Load/Store/Remove counterparts
+// below) take cs's own RWMutex, so concurrent callers sharing one CStore
+// serialize against each other: reads run shared, writes run exclusive.
+// Watch is unaffected - it reads its own debounced copy of the file via
+// cs.Load, which still takes the read lock below.
+
 func (cs *CStore) Get(v Validatable) error {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
 	return Get(v, cs.serializer)
 }
 
 func (cs *CStore) Save(v Validatable) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
 	return Save(v, cs.serializer)
 }
 
 func (cs *CStore) GetWithoutValidate(p interface{}) error {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
 	return GetWithoutValidate(p, cs.serializer)
 }
 
 func (cs *CStore) SaveWithoutValidate(p interface{}) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
 	return SaveWithoutValidate(p, cs.serializer)
 }
 
 func (cs *CStore) Load(p interface{}) error {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
 	return cs.serializer.Load(p)
 }
 
 func (cs *CStore) Store(p interface{}) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
 	return cs.serializer.Store(p)
 }
 
 func (cs *CStore) Remove() error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
 	return cs.serializer.Remove()
 }
 
@@ -214,15 +400,42 @@ func createDir(path string) error {
 	return nil
 }
 
+// TomlFile is the default TOML Serializable. AtomicWrite and FileLock
+// default to false on a bare struct literal; Manager.New/NewCStore turn
+// both on unless told otherwise via WithAtomicWrite/WithFileLock.
 type TomlFile struct {
-	FilePath string
+	FilePath    string
+	AtomicWrite bool
+	FileLock    bool
 }
 
 func (t *TomlFile) Load(p interface{}) error {
+	if t.FileLock {
+		flk, err := lockFile(t.FilePath, false)
+		if err != nil {
+			return err
+		}
+		defer flk.Unlock()
+	}
+
 	return LoadFromTomlFile(t.FilePath, p)
 }
 
 func (t *TomlFile) Store(p interface{}) error {
+	if t.FileLock {
+		flk, err := lockFile(t.FilePath, true)
+		if err != nil {
+			return err
+		}
+		defer flk.Unlock()
+	}
+
+	if t.AtomicWrite {
+		return atomicWriteFile(t.FilePath, func(f *os.File) error {
+			return toml.NewEncoder(f).Encode(p)
+		})
+	}
+
 	return StoreToTomlFile(t.FilePath, p)
 }
 
@@ -254,15 +467,42 @@ func StoreToTomlFile(filePath string, p interface{}) error {
 	return nil
 }
 
+// JsonFile is the default JSON Serializable. AtomicWrite and FileLock
+// default to false on a bare struct literal; Manager.New/NewCStore turn
+// both on unless told otherwise via WithAtomicWrite/WithFileLock.
 type JsonFile struct {
-	FilePath string
+	FilePath    string
+	AtomicWrite bool
+	FileLock    bool
 }
 
 func (f *JsonFile) Load(p interface{}) error {
+	if f.FileLock {
+		flk, err := lockFile(f.FilePath, false)
+		if err != nil {
+			return err
+		}
+		defer flk.Unlock()
+	}
+
 	return LoadFromJsonFile(f.FilePath, p)
 }
 
 func (f *JsonFile) Store(p interface{}) error {
+	if f.FileLock {
+		flk, err := lockFile(f.FilePath, true)
+		if err != nil {
+			return err
+		}
+		defer flk.Unlock()
+	}
+
+	if f.AtomicWrite {
+		return atomicWriteFile(f.FilePath, func(file *os.File) error {
+			return json.NewEncoder(file).Encode(p)
+		})
+	}
+
 	return StoreToJsonFile(f.FilePath, p)
 }
 
@@ -299,15 +539,48 @@ func LoadFromJsonFile(filePath string, p interface{}) error {
 	return dec.Decode(p)
 }
 
+// YamlFile is the default YAML Serializable. AtomicWrite and FileLock
+// default to false on a bare struct literal; Manager.New/NewCStore turn
+// both on unless told otherwise via WithAtomicWrite/WithFileLock.
 type YamlFile struct {
-	FilePath string
+	FilePath    string
+	AtomicWrite bool
+	FileLock    bool
 }
 
 func (f *YamlFile) Load(p interface{}) error {
+	if f.FileLock {
+		flk, err := lockFile(f.FilePath, false)
+		if err != nil {
+			return err
+		}
+		defer flk.Unlock()
+	}
+
 	return LoadFromYamlFile(f.FilePath, p)
 }
 
 func (f *YamlFile) Store(p interface{}) error {
+	if f.FileLock {
+		flk, err := lockFile(f.FilePath, true)
+		if err != nil {
+			return err
+		}
+		defer flk.Unlock()
+	}
+
+	if f.AtomicWrite {
+		return atomicWriteFile(f.FilePath, func(file *os.File) error {
+			bytes, err := yaml.Marshal(p)
+			if err != nil {
+				return err
+			}
+
+			_, err = file.Write(bytes)
+			return err
+		})
+	}
+
 	return StoreToYamlFile(f.FilePath, p)
 }
 
@@ -2,7 +2,9 @@ package cstore
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"sync"
 	"testing"
 )
 
@@ -141,3 +143,172 @@ func TestManager(t *testing.T) {
 		t.Fatalf("Get() should not return %s, because called Remove()", name)
 	}
 }
+
+func TestAtomicWriteAndFileLockOptions(t *testing.T) {
+	removeBaseDir(t)
+
+	m, err := NewManager("testing", BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := m.New("sample.json", JSON, WithAtomicWrite(false), WithFileLock(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Sample{Name: "sample name"}
+	if err := cs.Save(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	s2 := Sample{}
+	if err := cs.Get(&s2); err != nil {
+		t.Fatal(err)
+	}
+
+	if s2.Name != "sample name" {
+		t.Errorf("expect:%s but %s", "sample name", s2.Name)
+	}
+}
+
+// TestFileLockLoadsReadOnlyFile confirms lockFile opens Load's target
+// read-only: flock/LockFileEx doesn't need write access, and a Load-time
+// O_RDWR open would fail on a read-only file the caller never intends to
+// write.
+func TestFileLockLoadsReadOnlyFile(t *testing.T) {
+	removeBaseDir(t)
+
+	m, err := NewManager("testing", BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := m.New("sample.json", JSON, WithAtomicWrite(false), WithFileLock(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Sample{Name: "sample name"}
+	if err := cs.Save(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	filePath := BASE_DIR + string(os.PathSeparator) + "sample.json"
+	if err := os.Chmod(filePath, 0444); err != nil {
+		t.Fatal(err)
+	}
+
+	s2 := Sample{}
+	if err := cs.Get(&s2); err != nil {
+		t.Fatalf("Get on a read-only file should succeed, got: %v", err)
+	}
+
+	if s2.Name != "sample name" {
+		t.Errorf("expect:%s but %s", "sample name", s2.Name)
+	}
+}
+
+func TestMemoryBackend(t *testing.T) {
+	m, err := NewManager("testing", BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := m.New("sample.json", JSON, WithBackend(MemoryBackend()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Sample{Name: "sample name"}
+	if err := cs.Save(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	s2 := Sample{}
+	if err := cs.Get(&s2); err != nil {
+		t.Fatal(err)
+	}
+
+	if s2.Name != "sample name" {
+		t.Errorf("expect:%s but %s", "sample name", s2.Name)
+	}
+
+	if err := cs.Remove(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Get(&Sample{}); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
+
+// TestCStoreConcurrentSaveAndGet exercises CStore's RWMutex under -race:
+// concurrent Save/Get on one CStore should serialize rather than race.
+func TestCStoreConcurrentSaveAndGet(t *testing.T) {
+	m, err := NewManager("testing", BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := m.New("concurrent.json", JSON, WithBackend(MemoryBackend()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Save(&Sample{Name: "initial"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+			if err := cs.Save(&Sample{Name: "concurrent"}); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			var got Sample
+			if err := cs.Get(&got); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// TestManagerConcurrentAccess exercises Manager's RWMutex under -race:
+// concurrent New/Get/List/Range should serialize rather than race on csMap.
+func TestManagerConcurrentAccess(t *testing.T) {
+	m, err := NewManager("testing", BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("concurrent-%d.json", i)
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if _, err := m.New(name, JSON, WithBackend(MemoryBackend())); err != nil {
+				t.Error(err)
+				return
+			}
+
+			m.Get(name)
+			m.List()
+			m.Range(func(name string, cs *CStore) bool { return true })
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,269 @@
+package cstore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// KeyProvider supplies the 32-byte AES-256 data-encryption key EncryptedFile
+// uses to encrypt/decrypt a CStore's contents.
+type KeyProvider interface {
+	DataKey() ([]byte, error)
+}
+
+// passphraseKeyProvider derives a data key from a passphrase with scrypt.
+// salt is caller-supplied (and must stay constant across runs) since,
+// unlike the per-write nonce, it is not stored in the encrypted file header.
+type passphraseKeyProvider struct {
+	passphrase string
+	salt       []byte
+}
+
+// PassphraseKey derives the data key from passphrase via scrypt, using salt
+// to defend against rainbow-table attacks. salt must be kept the same
+// across runs (e.g. alongside the app's other config) or previously
+// encrypted files become unreadable.
+func PassphraseKey(passphrase string, salt []byte) KeyProvider {
+	return &passphraseKeyProvider{passphrase: passphrase, salt: salt}
+}
+
+// scrypt cost parameters, following the values scrypt's author recommends
+// for interactive logins: N=2^15, r=8, p=1.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+func (p *passphraseKeyProvider) DataKey() ([]byte, error) {
+	return scrypt.Key([]byte(p.passphrase), p.salt, scryptN, scryptR, scryptP, 32)
+}
+
+// envKeyProvider reads a base64-encoded 32-byte key from an environment
+// variable.
+type envKeyProvider struct {
+	envVar string
+}
+
+// EnvKey reads the data key from the environment variable envVar, base64
+// encoded (standard encoding), so it can be injected by whatever secret
+// manager the deployment already uses.
+func EnvKey(envVar string) KeyProvider {
+	return &envKeyProvider{envVar: envVar}
+}
+
+func (p *envKeyProvider) DataKey() ([]byte, error) {
+	v := os.Getenv(p.envVar)
+	if v == "" {
+		return nil, fmt.Errorf("cstore: environment variable %q is not set", p.envVar)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		return nil, fmt.Errorf("cstore: %s: %w", p.envVar, err)
+	}
+
+	if len(key) != 32 {
+		return nil, fmt.Errorf("cstore: %s: key must decode to 32 bytes, got %d", p.envVar, len(key))
+	}
+
+	return key, nil
+}
+
+// kmsKeyProvider resolves a data key by asking unwrap to decrypt a DEK that
+// was wrapped by a cloud KMS, so the raw key itself never touches disk.
+type kmsKeyProvider struct {
+	wrappedDEK []byte
+	unwrap     func(wrappedDEK []byte) ([]byte, error)
+}
+
+// KMSKey builds a KeyProvider around a cloud KMS: wrappedDEK is the
+// encrypted data-encryption key as stored alongside the app's config, and
+// unwrap is the callback that asks the KMS to decrypt it (e.g. a
+// kms.Client.Decrypt call).
+func KMSKey(wrappedDEK []byte, unwrap func(wrappedDEK []byte) ([]byte, error)) KeyProvider {
+	return &kmsKeyProvider{wrappedDEK: wrappedDEK, unwrap: unwrap}
+}
+
+func (p *kmsKeyProvider) DataKey() ([]byte, error) {
+	key, err := p.unwrap(p.wrappedDEK)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key) != 32 {
+		return nil, fmt.Errorf("cstore: KMS unwrap returned %d bytes, want 32", len(key))
+	}
+
+	return key, nil
+}
+
+var encryptedFileMagic = [4]byte{'C', 'S', 'E', '1'}
+
+const encryptedFileVersion = 1
+
+// encryptBytes encrypts plaintext with AES-256-GCM under key, returning
+// magic || version || nonce || ciphertext || tag.
+func encryptBytes(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(encryptedFileMagic)+1+len(nonce)+len(plaintext)+gcm.Overhead())
+	out = append(out, encryptedFileMagic[:]...)
+	out = append(out, encryptedFileVersion)
+	out = append(out, nonce...)
+	out = gcm.Seal(out, nonce, plaintext, nil)
+
+	return out, nil
+}
+
+// decryptBytes reverses encryptBytes.
+func decryptBytes(key, data []byte) ([]byte, error) {
+	headerLen := len(encryptedFileMagic) + 1
+	if len(data) < headerLen {
+		return nil, fmt.Errorf("cstore: encrypted file is too short")
+	}
+
+	if [4]byte{data[0], data[1], data[2], data[3]} != encryptedFileMagic {
+		return nil, fmt.Errorf("cstore: not a cstore-encrypted file")
+	}
+
+	if data[4] != encryptedFileVersion {
+		return nil, fmt.Errorf("cstore: unsupported encrypted file version %d", data[4])
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := data[headerLen:]
+	if len(rest) < gcm.NonceSize() {
+		return nil, fmt.Errorf("cstore: encrypted file is too short")
+	}
+
+	nonce, ciphertext := rest[:gcm.NonceSize()], rest[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+// encryptingBackend wraps another Backend, encrypting bytes on Store and
+// decrypting them on Load, so any Backend gains encryption at rest without
+// its own code changing.
+type encryptingBackend struct {
+	inner       Backend
+	keyProvider KeyProvider
+}
+
+func (b *encryptingBackend) Load(key string) ([]byte, error) {
+	data, err := b.inner.Load(key)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := b.keyProvider.DataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptBytes(dataKey, data)
+}
+
+func (b *encryptingBackend) Store(key string, data []byte) error {
+	dataKey, err := b.keyProvider.DataKey()
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := encryptBytes(dataKey, data)
+	if err != nil {
+		return err
+	}
+
+	return b.inner.Store(key, ciphertext)
+}
+
+func (b *encryptingBackend) Remove(key string) error {
+	return b.inner.Remove(key)
+}
+
+// EncryptedFile is a Serializable that transparently wraps the same
+// encode/decode TomlFile/JsonFile/YamlFile use (see encodeFormat/
+// decodeFormat), encrypting the result at rest with AES-256-GCM envelope
+// encryption before it reaches backend. Build one with WithEncryption
+// rather than constructing it directly.
+type EncryptedFile struct {
+	serializer Serializable
+}
+
+func newEncryptedFile(key string, format Format, backend Backend, keyProvider KeyProvider) *EncryptedFile {
+	return &EncryptedFile{
+		serializer: &BackendSerializer{
+			Key:    key,
+			Format: format,
+			Backend: &encryptingBackend{
+				inner:       backend,
+				keyProvider: keyProvider,
+			},
+		},
+	}
+}
+
+func (e *EncryptedFile) Load(p interface{}) error {
+	return e.serializer.Load(p)
+}
+
+func (e *EncryptedFile) Store(p interface{}) error {
+	return e.serializer.Store(p)
+}
+
+func (e *EncryptedFile) Remove() error {
+	return e.serializer.Remove()
+}
+
+// isEncrypted reports whether s is (or wraps) an EncryptedFile, so Convert
+// can refuse to write a plaintext copy of an encrypted CStore's value
+// instead of silently exfiltrating it.
+func isEncrypted(s Serializable) bool {
+	switch v := s.(type) {
+	case *EncryptedFile:
+		return true
+	case *SchemaSerializer:
+		return isEncrypted(v.inner)
+	default:
+		return false
+	}
+}
+
+// WithEncryption routes Save/Load through an EncryptedFile keyed by
+// provider, so an existing format (TOML/JSON/YAML, or one registered via
+// RegisterFormat) gains encryption at rest without any call site changing.
+// It composes with WithBackend: the chosen backend (FileBackend by
+// default) is what the encrypted bytes are ultimately stored to.
+func WithEncryption(provider KeyProvider) CStoreOption {
+	return func(c *cstoreConfig) {
+		c.keyProvider = provider
+	}
+}
@@ -0,0 +1,100 @@
+package cstore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestWithEncryptionRoundTrips(t *testing.T) {
+	removeBaseDir(t)
+
+	m, err := NewManager("testing", BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := PassphraseKey("hunter2", []byte("fixed-salt"))
+
+	cs, err := m.New("secret.json", JSON, WithEncryption(provider))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sText := Text{Text: "this message"}
+	if err := cs.SaveWithoutValidate(&sText); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := ioutil.ReadFile(BASE_DIR + "/secret.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(raw[:4]) == `{"Te` {
+		t.Fatal("expected the stored file to be encrypted, found plaintext JSON")
+	}
+
+	gText := Text{}
+	if err := cs.GetWithoutValidate(&gText); err != nil {
+		t.Fatal(err)
+	}
+
+	if gText.Text != sText.Text {
+		t.Errorf("expect %s but got %s", sText.Text, gText.Text)
+	}
+}
+
+func TestConvertRefusesEncryptedSource(t *testing.T) {
+	removeBaseDir(t)
+
+	m, err := NewManager("testing", BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := m.New("secret.json", JSON, WithEncryption(PassphraseKey("hunter2", []byte("fixed-salt"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.SaveWithoutValidate(&Text{Text: "this message"}); err != nil {
+		t.Fatal(err)
+	}
+
+	dstPath := BASE_DIR + string(os.PathSeparator) + "secret-converted.yaml"
+	if err := cs.Convert(dstPath, YAML); err == nil {
+		t.Fatal("expected Convert to refuse an encrypted source instead of writing it out as plaintext")
+	}
+
+	if _, err := os.Stat(dstPath); !os.IsNotExist(err) {
+		t.Fatalf("expected no file to be written at %s, got err: %v", dstPath, err)
+	}
+}
+
+func TestWithEncryptionRejectsWrongPassphrase(t *testing.T) {
+	removeBaseDir(t)
+
+	m, err := NewManager("testing", BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := m.New("secret.json", JSON, WithEncryption(PassphraseKey("hunter2", []byte("fixed-salt"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.SaveWithoutValidate(&Text{Text: "this message"}); err != nil {
+		t.Fatal(err)
+	}
+
+	wrong, err := m.New("secret.json", JSON, WithEncryption(PassphraseKey("wrong-passphrase", []byte("fixed-salt"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := wrong.GetWithoutValidate(&Text{}); err == nil {
+		t.Fatal("expected an error decrypting with the wrong passphrase")
+	}
+}
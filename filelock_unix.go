@@ -0,0 +1,47 @@
+//go:build !windows
+// +build !windows
+
+package cstore
+
+import (
+	"os"
+	"syscall"
+)
+
+// lock is an OS-level advisory lock held on an open file handle, released
+// by Unlock.
+type lock struct {
+	f *os.File
+}
+
+// lockFile takes an exclusive flock on filePath, blocking until it's
+// available. createIfMissing controls whether the file is created if it
+// doesn't exist yet: Store wants that, but Load must not silently conjure
+// an empty file for a not-yet-existing path, or a missing file stops
+// looking like one (see the os.IsNotExist checks in cstore_test.go). flock
+// itself needs no write access to the fd, so Load opens read-only rather
+// than O_RDWR, which would otherwise fail locking a read-only file it never
+// intends to write.
+func lockFile(filePath string, createIfMissing bool) (*lock, error) {
+	flags := os.O_RDONLY
+	if createIfMissing {
+		flags = os.O_RDWR | os.O_CREATE
+	}
+
+	f, err := os.OpenFile(filePath, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &lock{f: f}, nil
+}
+
+func (l *lock) Unlock() error {
+	defer l.f.Close()
+	return syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,49 @@
+//go:build windows
+// +build windows
+
+package cstore
+
+import (
+	"os"
+	"syscall"
+)
+
+// lock is an OS-level advisory lock held on an open file handle, released
+// by Unlock.
+type lock struct {
+	f *os.File
+}
+
+// lockFile takes an exclusive LockFileEx lock on filePath, blocking until
+// it's available. createIfMissing controls whether the file is created if
+// it doesn't exist yet: Store wants that, but Load must not silently
+// conjure an empty file for a not-yet-existing path, or a missing file
+// stops looking like one (see the os.IsNotExist checks in cstore_test.go).
+// LockFileEx itself needs no write access to the handle, so Load opens
+// read-only rather than O_RDWR, which would otherwise fail locking a
+// read-only file it never intends to write.
+func lockFile(filePath string, createIfMissing bool) (*lock, error) {
+	flags := os.O_RDONLY
+	if createIfMissing {
+		flags = os.O_RDWR | os.O_CREATE
+	}
+
+	f, err := os.OpenFile(filePath, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	ol := new(syscall.Overlapped)
+	if err := syscall.LockFileEx(syscall.Handle(f.Fd()), syscall.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, ol); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &lock{f: f}, nil
+}
+
+func (l *lock) Unlock() error {
+	defer l.f.Close()
+	ol := new(syscall.Overlapped)
+	return syscall.UnlockFileEx(syscall.Handle(l.f.Fd()), 0, 1, 0, ol)
+}
@@ -0,0 +1,178 @@
+package cstore
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Encoder serializes a value for a registered Format.
+type Encoder interface {
+	Encode(p interface{}) ([]byte, error)
+}
+
+// Decoder deserializes bytes produced by the matching Encoder back into p.
+type Decoder interface {
+	Decode(data []byte, p interface{}) error
+}
+
+type registeredFormat struct {
+	name string
+	ext  []string
+	enc  Encoder
+	dec  Decoder
+}
+
+// formatRegistryMu guards customFormats, nextCustomFormat, and formatCodec
+// (in cstore.go), since RegisterFormat can run concurrently with the
+// encodeFormat/decodeFormat/formatForExt/formatForName/NewCStore lookups
+// that read them.
+var formatRegistryMu sync.RWMutex
+
+// customFormats holds formats added via RegisterFormat, keyed by the Format
+// value RegisterFormat returns. TOML/JSON/YAML are handled directly by
+// encodeFormat/decodeFormat and never appear here. Guarded by
+// formatRegistryMu.
+var customFormats = make(map[Format]*registeredFormat)
+
+// nextCustomFormat is the next Format value RegisterFormat will hand out.
+// It starts well above TOML/JSON/YAML so registered formats never collide
+// with the built-ins. Guarded by formatRegistryMu.
+var nextCustomFormat = Format(100)
+
+// RegisterFormat adds a new Format backed by enc/dec, so NewCStore and
+// Manager.New accept it without any change to their TOML/JSON/YAML switch.
+// ext lists the file extensions (e.g. ".hcl") that should resolve to this
+// format from a URL passed to Manager.NewFromURL. RegisterFormat returns
+// the Format value to pass to New/NewCStore/Convert.
+func RegisterFormat(name string, ext []string, enc Encoder, dec Decoder) Format {
+	formatRegistryMu.Lock()
+	defer formatRegistryMu.Unlock()
+
+	f := nextCustomFormat
+	nextCustomFormat++
+
+	customFormats[f] = &registeredFormat{
+		name: name,
+		ext:  ext,
+		enc:  enc,
+		dec:  dec,
+	}
+	formatCodec[f] = struct{}{}
+
+	return f
+}
+
+// formatForExt looks up a Format registered via RegisterFormat by file
+// extension (e.g. ".hcl"), for Manager.NewFromURL to fall back to after the
+// built-in TOML/JSON/YAML extensions.
+func formatForExt(ext string) (Format, bool) {
+	formatRegistryMu.RLock()
+	defer formatRegistryMu.RUnlock()
+
+	for f, rf := range customFormats {
+		for _, e := range rf.ext {
+			if e == ext {
+				return f, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// formatForName looks up a Format registered via RegisterFormat by the name
+// it was registered under, for Manager.NewFromURL's ?format= query param.
+func formatForName(name string) (Format, bool) {
+	formatRegistryMu.RLock()
+	defer formatRegistryMu.RUnlock()
+
+	for f, rf := range customFormats {
+		if rf.name == name {
+			return f, true
+		}
+	}
+
+	return 0, false
+}
+
+// Convert decodes cs's current value and re-encodes it at dstPath in
+// dstFormat, so callers can migrate e.g. a TOML config to YAML in one call.
+// The intermediate value is normalized to map[string]interface{} so a YAML
+// source's map[interface{}]interface{} doesn't leak into the destination
+// encoder. Convert refuses to run against an encrypted CStore: dstPath is
+// always written through a bare FileBackend, and writing it plaintext would
+// silently undo the encryption at rest cs was built with. Build the
+// destination CStore with its own WithEncryption and Save into it instead.
+func (cs *CStore) Convert(dstPath string, dstFormat Format) error {
+	cs.mu.RLock()
+	encrypted := isEncrypted(cs.serializer)
+	if encrypted {
+		cs.mu.RUnlock()
+		return fmt.Errorf("cstore: %s: refusing to Convert an encrypted CStore to a plaintext file", cs.name)
+	}
+
+	var raw map[string]interface{}
+	loadErr := cs.serializer.Load(&raw)
+	cs.mu.RUnlock()
+
+	if loadErr != nil {
+		return loadErr
+	}
+
+	normalized, err := normalizeDecodedValue(raw)
+	if err != nil {
+		return err
+	}
+
+	nm, ok := normalized.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("cstore: decoded value is not a map[string]interface{}: %T", normalized)
+	}
+
+	data, err := encodeFormat(dstFormat, nm)
+	if err != nil {
+		return err
+	}
+
+	return (&FileBackend{}).Store(dstPath, data)
+}
+
+// normalizeDecodedValue recursively rewrites map[interface{}]interface{}
+// (what yaml.v2 produces for nested maps) into map[string]interface{}, so
+// a value decoded from any of TOML/JSON/YAML ends up in the same shape.
+func normalizeDecodedValue(v interface{}) (interface{}, error) {
+	switch vv := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(vv))
+		for k, val := range vv {
+			nv, err := normalizeDecodedValue(val)
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(k)] = nv
+		}
+		return m, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(vv))
+		for k, val := range vv {
+			nv, err := normalizeDecodedValue(val)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = nv
+		}
+		return m, nil
+	case []interface{}:
+		out := make([]interface{}, len(vv))
+		for i, val := range vv {
+			nv, err := normalizeDecodedValue(val)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = nv
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
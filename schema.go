@@ -0,0 +1,218 @@
+package cstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// jsonSchema is the subset of JSON Schema this package understands: object/
+// array/scalar "type", "required" fields, nested "properties", and a
+// "default" value applied when a property is missing or zero-valued. It's
+// enough to give CStore's values the "schema + defaults + effective value"
+// shape without every struct hand-implementing Validate().
+type jsonSchema struct {
+	Type       string                 `json:"type"`
+	Required   []string               `json:"required"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Items      *jsonSchema            `json:"items"`
+	Default    interface{}            `json:"default"`
+}
+
+// WithSchema associates a JSON Schema with a CStore: Load applies any
+// declared defaults for fields missing or zero-valued in the stored value before
+// validating it, and Save validates before writing. schemaBytes is parsed
+// once, at construction.
+func WithSchema(schemaBytes []byte) CStoreOption {
+	return func(c *cstoreConfig) {
+		c.schema = schemaBytes
+	}
+}
+
+// SchemaSerializer wraps another Serializable, applying JSON Schema
+// defaults and validation around its Load/Store. It round-trips the value
+// through JSON regardless of the wrapped serializer's format, since TOML,
+// JSON, and YAML all decode into comparable Go values.
+type SchemaSerializer struct {
+	inner  Serializable
+	schema *jsonSchema
+}
+
+func newSchemaSerializer(inner Serializable, schemaBytes []byte) (*SchemaSerializer, error) {
+	var schema jsonSchema
+	if err := json.Unmarshal(schemaBytes, &schema); err != nil {
+		return nil, fmt.Errorf("cstore: invalid schema: %w", err)
+	}
+
+	return &SchemaSerializer{inner: inner, schema: &schema}, nil
+}
+
+func (s *SchemaSerializer) Load(p interface{}) error {
+	if err := s.inner.Load(p); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	applySchemaDefaults(raw, s.schema)
+
+	if err := validateAgainstSchema(raw, s.schema, "$"); err != nil {
+		return err
+	}
+
+	data, err = json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, p)
+}
+
+func (s *SchemaSerializer) Store(p interface{}) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if err := validateAgainstSchema(raw, s.schema, "$"); err != nil {
+		return err
+	}
+
+	return s.inner.Store(p)
+}
+
+func (s *SchemaSerializer) Remove() error {
+	return s.inner.Remove()
+}
+
+// applySchemaDefaults fills in schema.Properties[name].Default for any
+// property missing or zero-valued in v, recursing into nested objects. A
+// plain (non-pointer, no omitempty) Go struct field always round-trips
+// through encoding/json, so "missing" alone would never fire for the
+// common case this feature targets; isZeroJSONValue treats an explicit
+// zero the same as absent, matching what encoding/json's own omitempty
+// considers empty.
+func applySchemaDefaults(v interface{}, schema *jsonSchema) {
+	if schema == nil || len(schema.Properties) == 0 {
+		return
+	}
+
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	for name, propSchema := range schema.Properties {
+		child, ok := obj[name]
+		if (!ok || isZeroJSONValue(child)) && propSchema.Default != nil {
+			obj[name] = propSchema.Default
+			child, ok = propSchema.Default, true
+		}
+
+		if ok {
+			applySchemaDefaults(child, propSchema)
+		}
+	}
+}
+
+// isZeroJSONValue reports whether v is the zero value encoding/json would
+// produce for its type (nil, false, 0, "", or an empty array/object), so
+// applySchemaDefaults can treat "present but zero" the same as "absent" for
+// a plain struct field. validateAgainstSchema's required check deliberately
+// does not use this: false/0/""/[] are legitimate values for a required
+// field, and only a genuinely absent key should fail validation.
+func isZeroJSONValue(v interface{}) bool {
+	switch vv := v.(type) {
+	case nil:
+		return true
+	case bool:
+		return !vv
+	case float64:
+		return vv == 0
+	case string:
+		return vv == ""
+	case []interface{}:
+		return len(vv) == 0
+	case map[string]interface{}:
+		return len(vv) == 0
+	default:
+		return false
+	}
+}
+
+// validateAgainstSchema checks v's type, required properties, and nested
+// properties against schema, reporting the first mismatch found.
+func validateAgainstSchema(v interface{}, schema *jsonSchema, path string) error {
+	if schema == nil {
+		return nil
+	}
+
+	if schema.Type != "" && !schemaTypeMatches(v, schema.Type) {
+		return fmt.Errorf("cstore: %s: expected type %q, got %T", path, schema.Type, v)
+	}
+
+	if len(schema.Required) == 0 && len(schema.Properties) == 0 {
+		return nil
+	}
+
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("cstore: %s: expected an object, got %T", path, v)
+	}
+
+	for _, name := range schema.Required {
+		if _, ok := obj[name]; !ok {
+			return fmt.Errorf("cstore: %s: missing required field %q", path, name)
+		}
+	}
+
+	for name, propSchema := range schema.Properties {
+		if child, ok := obj[name]; ok {
+			if err := validateAgainstSchema(child, propSchema, path+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func schemaTypeMatches(v interface{}, t string) bool {
+	switch t {
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "null":
+		return v == nil
+	default:
+		return true
+	}
+}
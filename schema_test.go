@@ -0,0 +1,48 @@
+package cstore
+
+import "testing"
+
+type AppConfig struct {
+	Name string `json:"name,omitempty"`
+	Port int    `json:"port"`
+}
+
+func TestWithSchemaAppliesDefaultsAndValidates(t *testing.T) {
+	removeBaseDir(t)
+
+	schema := []byte(`{
+		"type": "object",
+		"required": ["name"],
+		"properties": {
+			"name": {"type": "string"},
+			"port": {"type": "integer", "default": 8080}
+		}
+	}`)
+
+	m, err := NewManager("testing", BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := m.New("app.json", JSON, WithSchema(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.SaveWithoutValidate(&AppConfig{Name: "svc"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := AppConfig{}
+	if err := cs.GetWithoutValidate(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Port != 8080 {
+		t.Errorf("expect default port 8080 but got %d", got.Port)
+	}
+
+	if err := cs.SaveWithoutValidate(&AppConfig{Port: 1}); err == nil {
+		t.Fatal("expected an error saving a value missing the required \"name\" field")
+	}
+}
@@ -0,0 +1,207 @@
+package cstore
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewFromURLFile(t *testing.T) {
+	removeBaseDir(t)
+
+	m, err := NewManager("testing", BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	absBaseDir, err := filepath.Abs(BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := m.NewFromURL("text.toml", "file://"+absBaseDir+"/text.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sText := Text{Text: "this message"}
+	if err := cs.SaveWithoutValidate(&sText); err != nil {
+		t.Fatal(err)
+	}
+
+	gText := Text{}
+	if err := cs.GetWithoutValidate(&gText); err != nil {
+		t.Fatal(err)
+	}
+
+	if gText.Text != sText.Text {
+		t.Errorf("expect:%s but %s", sText.Text, gText.Text)
+	}
+}
+
+func TestNewFromURLFileDefaultsAtomicWriteAndFileLock(t *testing.T) {
+	removeBaseDir(t)
+
+	m, err := NewManager("testing", BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	absBaseDir, err := filepath.Abs(BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := m.NewFromURL("text.toml", "file://"+absBaseDir+"/text.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tf, ok := cs.serializer.(*TomlFile)
+	if !ok {
+		t.Fatalf("expected *TomlFile, got %T", cs.serializer)
+	}
+
+	if !tf.AtomicWrite || !tf.FileLock {
+		t.Errorf("expected AtomicWrite and FileLock both true, got AtomicWrite=%v FileLock=%v", tf.AtomicWrite, tf.FileLock)
+	}
+}
+
+func TestNewFromURLFileRejectsHost(t *testing.T) {
+	if _, err := (&Manager{}).NewFromURL("text.toml", "file://./relative/text.toml"); err == nil {
+		t.Fatal("expected an error for a file URL with a host")
+	}
+}
+
+func TestNewFromURLFileRegisteredFormat(t *testing.T) {
+	removeBaseDir(t)
+
+	RegisterFormat("urlsource-upper", []string{".upper"}, upperEncoder{}, upperDecoder{})
+
+	m, err := NewManager("testing", BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	absBaseDir, err := filepath.Abs(BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := m.NewFromURL("sample.upper", "file://"+absBaseDir+"/sample.upper")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Sample{Name: "sample name"}
+	if err := cs.SaveWithoutValidate(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	s2 := Sample{}
+	if err := cs.GetWithoutValidate(&s2); err != nil {
+		t.Fatal(err)
+	}
+
+	if s2.Name != s.Name {
+		t.Errorf("expect:%s but %s", s.Name, s2.Name)
+	}
+}
+
+func TestNewFromURLHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&Sample{Name: "sample name"})
+	}))
+	defer srv.Close()
+
+	m, err := NewManager("testing", BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := m.NewFromURL("sample", srv.URL+"/sample.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Sample{}
+	if err := cs.GetWithoutValidate(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Name != "sample name" {
+		t.Errorf("expect:%s but %s", "sample name", s.Name)
+	}
+
+	if err := cs.SaveWithoutValidate(&s); err == nil {
+		t.Fatal("expected an error saving to a read-only http source")
+	}
+}
+
+func TestNewFromURLS3(t *testing.T) {
+	removeBaseDir(t)
+
+	store := newFakeObjectStore()
+	RegisterObjectStore("testing-bucket", store)
+
+	m, err := NewManager("testing", BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := m.NewFromURL("sample", "s3://testing-bucket/sample?format=json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Sample{Name: "sample name"}
+	if err := cs.SaveWithoutValidate(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := store.data["sample"]; !ok {
+		t.Fatalf("expected the object to be stored under key %q, got: %v", "sample", store.data)
+	}
+
+	got := Sample{}
+	if err := cs.GetWithoutValidate(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Name != s.Name {
+		t.Errorf("expect:%s but %s", s.Name, got.Name)
+	}
+}
+
+func TestNewFromURLS3UnregisteredBucket(t *testing.T) {
+	m, err := NewManager("testing", BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := m.NewFromURL("sample", "s3://no-such-bucket/sample?format=json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.GetWithoutValidate(&Sample{}); err == nil {
+		t.Fatal("expected an error loading from an unregistered bucket")
+	}
+}
+
+func TestFormatFromURLRequiresQueryWithoutExtension(t *testing.T) {
+	m, err := NewManager("testing", BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.NewFromURL("local", "stdin://local?format=json"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.NewFromURL("local", "stdin://local"); err == nil {
+		t.Fatal("expected an error when format cannot be inferred")
+	}
+}
@@ -0,0 +1,199 @@
+package cstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// EventType identifies what CStore.Watch observed: Loaded whenever the
+// underlying file is written (or created, e.g. by an atomic rename),
+// Removed when it disappears, and EventError when fsnotify or a re-decode
+// fails.
+type EventType int
+
+const (
+	Loaded EventType = iota
+	Removed
+	EventError
+)
+
+func (t EventType) String() string {
+	switch t {
+	case Loaded:
+		return "Loaded"
+	case Removed:
+		return "Removed"
+	case EventError:
+		return "Error"
+	default:
+		return "Unknown"
+	}
+}
+
+// Event is delivered on the channel CStore.Watch returns. Value is only set
+// for a Loaded event; Err is only set for an EventError one.
+type Event struct {
+	Type  EventType
+	Value interface{}
+	Err   error
+}
+
+// watchDebounce is how long Watch waits after the last fsnotify event on its
+// path before re-decoding, so the several WRITE/CREATE/CHMOD events an
+// editor (or atomicWriteFile's rename) produces for one save collapse into
+// a single Loaded event.
+const watchDebounce = 100 * time.Millisecond
+
+// filePather is implemented by the Serializables that are ultimately backed
+// by a single on-disk file, so Watch knows which path to hand fsnotify.
+// Serializables backed by Redis/object-storage/in-memory Backends report
+// false from watchPath, stdin/http sources don't implement it, and Watch
+// reports either case up front instead of watching nothing.
+type filePather interface {
+	watchPath() (string, bool)
+}
+
+func (t *TomlFile) watchPath() (string, bool) { return t.FilePath, true }
+func (f *JsonFile) watchPath() (string, bool) { return f.FilePath, true }
+func (f *YamlFile) watchPath() (string, bool) { return f.FilePath, true }
+
+func (s *BackendSerializer) watchPath() (string, bool) {
+	switch b := s.Backend.(type) {
+	case *FileBackend:
+		return s.Key, true
+	case *encryptingBackend:
+		if _, ok := b.inner.(*FileBackend); ok {
+			return s.Key, true
+		}
+	}
+
+	return "", false
+}
+
+func (s *SchemaSerializer) watchPath() (string, bool) {
+	if p, ok := s.inner.(filePather); ok {
+		return p.watchPath()
+	}
+
+	return "", false
+}
+
+func (e *EncryptedFile) watchPath() (string, bool) {
+	if p, ok := e.serializer.(filePather); ok {
+		return p.watchPath()
+	}
+
+	return "", false
+}
+
+// Watch observes cs's underlying file and sends an Event each time it
+// changes, so a long-running service can react to an operator editing its
+// config without restarting. newValue must return a fresh pointer suitable
+// for cs.Load (e.g. func() interface{} { return &AppConfig{} }); it's
+// called once per re-decode (whether it ends in a Loaded or an EventError
+// event), since the channel can't reuse one value across concurrent readers.
+//
+// Watch follows atomicWriteFile's temp-file-then-rename scheme by watching
+// cs's parent directory rather than the file itself: a bare file watch
+// would be left pointing at the old (now-unlinked) inode after a rename,
+// silently going dead. The returned channel is closed, and the watch torn
+// down, when ctx is done.
+func (cs *CStore) Watch(ctx context.Context, newValue func() interface{}) (<-chan Event, error) {
+	p, ok := cs.serializer.(filePather)
+	if !ok {
+		return nil, fmt.Errorf("cstore: %s: Watch requires a file-backed CStore", cs.name)
+	}
+
+	path, ok := p.watchPath()
+	if !ok {
+		return nil, fmt.Errorf("cstore: %s: Watch requires a file-backed CStore", cs.name)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	events := make(chan Event)
+	go cs.watchLoop(ctx, watcher, path, newValue, events)
+
+	return events, nil
+}
+
+func (cs *CStore) watchLoop(ctx context.Context, watcher *fsnotify.Watcher, path string, newValue func() interface{}, events chan<- Event) {
+	defer watcher.Close()
+	defer close(events)
+
+	base := filepath.Base(path)
+	relevant := fsnotify.Create | fsnotify.Write | fsnotify.Rename | fsnotify.Remove
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+
+			if !sendEvent(ctx, events, Event{Type: EventError, Err: err}) {
+				return
+			}
+
+		case wevent, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			if filepath.Base(wevent.Name) != base || wevent.Op&relevant == 0 {
+				continue
+			}
+
+			debounce = time.After(watchDebounce)
+
+		case <-debounce:
+			debounce = nil
+
+			if !sendEvent(ctx, events, cs.loadWatchEvent(path, newValue)) {
+				return
+			}
+		}
+	}
+}
+
+// loadWatchEvent re-decodes path (or reports its removal) for watchLoop.
+func (cs *CStore) loadWatchEvent(path string, newValue func() interface{}) Event {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return Event{Type: Removed}
+	}
+
+	v := newValue()
+	if err := cs.Load(v); err != nil {
+		return Event{Type: EventError, Err: err}
+	}
+
+	return Event{Type: Loaded, Value: v}
+}
+
+// sendEvent delivers event to events, giving up and reporting false if ctx
+// is done first.
+func sendEvent(ctx context.Context, events chan<- Event, event Event) bool {
+	select {
+	case events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
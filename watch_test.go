@@ -0,0 +1,74 @@
+package cstore
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWatchSendsLoadedOnWrite(t *testing.T) {
+	removeBaseDir(t)
+
+	m, err := NewManager("testing", BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := m.New("watched.json", JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.SaveWithoutValidate(&Text{Text: "first"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := cs.Watch(ctx, func() interface{} { return &Text{} })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.SaveWithoutValidate(&Text{Text: "second"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-events:
+		if event.Type != Loaded {
+			t.Fatalf("expected a Loaded event, got %s (err: %v)", event.Type, event.Err)
+		}
+
+		got, ok := event.Value.(*Text)
+		if !ok {
+			t.Fatalf("expected *Text, got %T", event.Value)
+		}
+
+		if got.Text != "second" {
+			t.Errorf("expect %s but got %s", "second", got.Text)
+		}
+
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for Loaded event")
+	}
+}
+
+func TestWatchRejectsNonFileBackedCStore(t *testing.T) {
+	removeBaseDir(t)
+
+	m, err := NewManager("testing", BASE_DIR)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := m.New("memory.json", JSON, WithBackend(MemoryBackend()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cs.Watch(context.Background(), func() interface{} { return &Text{} }); err == nil {
+		t.Fatal("expected Watch to reject a non-file-backed CStore")
+	}
+}